@@ -0,0 +1,19 @@
+package vips
+
+// Backend is the resize/metadata surface this package exposes; SetBackend
+// lets a caller (or Initialize, on libvips startup failure) swap the
+// implementation actually used. Built without cgo (or without libvips
+// installed), defaultBackend is a goBackend from the start; see
+// backend_cgo.go and backend_nocgo.go.
+type Backend interface {
+	Resize(buf []byte, o Options) ([]byte, error)
+	Metadata(buf []byte) (ImageMetadata, error)
+}
+
+// SetBackend replaces the backend used by package-level convenience callers
+// such as Image. Calling it does not affect the libvips-backed Resize and
+// Metadata functions, which always use libvips directly and are only
+// available in cgo builds.
+func SetBackend(b Backend) {
+	defaultBackend = b
+}
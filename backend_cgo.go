@@ -0,0 +1,13 @@
+//go:build cgo
+
+package vips
+
+// vipsBackend is the default Backend in cgo builds, delegating to the
+// cgo-backed Resize and Metadata functions. Initialize replaces it with a
+// goBackend if libvips fails to start at runtime.
+type vipsBackend struct{}
+
+func (vipsBackend) Resize(buf []byte, o Options) ([]byte, error) { return Resize(buf, o) }
+func (vipsBackend) Metadata(buf []byte) (ImageMetadata, error)   { return Metadata(buf) }
+
+var defaultBackend Backend = vipsBackend{}
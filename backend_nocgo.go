@@ -0,0 +1,14 @@
+//go:build !cgo
+
+package vips
+
+// Without cgo there is no libvips binding to compile (vips.go and
+// metadata.go both require it), so the pure-Go backend is the only option.
+var defaultBackend Backend = goBackend{}
+
+// Initialize, Shutdown and Debug are no-ops in non-cgo builds: the goBackend
+// above needs no setup, and these exist only so callers don't have to guard
+// every call site with a build tag of their own.
+func Initialize() {}
+func Shutdown()   {}
+func Debug()      {}
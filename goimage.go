@@ -0,0 +1,167 @@
+package vips
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+)
+
+// goBackend is a pure-Go fallback requiring no cgo or libvips at runtime. It
+// supports JPEG and PNG source and output only (Resize rejects a WEBP/TIFF
+// Options.Type) and implements a smaller subset of Options
+// (Width/Height/Crop/Gravity/Enlarge/Quality); fields it can't honour —
+// rotate/flip, extract-area, zoom, blur, and AutoRotate on a JPEG source —
+// make Resize return an error rather than being silently dropped.
+type goBackend struct{}
+
+// goBackendSupports reports an error for any Options field goBackend can't
+// honour, rather than silently ignoring it: a caller asking for a rotate or
+// a blur should see that request rejected, not get back a plain resize.
+func goBackendSupports(o Options) error {
+	switch {
+	case o.Rotate != D0:
+		return errors.New("pure-Go backend: Rotate is not supported")
+	case o.Flip:
+		return errors.New("pure-Go backend: Flip is not supported")
+	case o.Flop:
+		return errors.New("pure-Go backend: Flop is not supported")
+	case o.CropRect != nil:
+		return errors.New("pure-Go backend: CropRect is not supported")
+	case o.AreaWidth > 0 || o.AreaHeight > 0:
+		return errors.New("pure-Go backend: AreaWidth/AreaHeight extraction is not supported")
+	case o.Zoom > 1:
+		return errors.New("pure-Go backend: Zoom is not supported")
+	case o.BlurAmount > 0:
+		return errors.New("pure-Go backend: BlurAmount is not supported")
+	case o.Type == WEBP || o.Type == TIFF:
+		return errors.New("pure-Go backend: output type WEBP/TIFF is not supported")
+	default:
+		return nil
+	}
+}
+
+func (goBackend) Resize(buf []byte, o Options) ([]byte, error) {
+	if err := goBackendSupports(o); err != nil {
+		return nil, err
+	}
+
+	typ := detectImageType(buf)
+
+	// Unlike the vips backend, goBackend never reads the EXIF orientation
+	// tag, so it can't honour AutoRotate's "on by default" promise for
+	// JPEG sources; reject rather than risk silently returning a
+	// sideways/mirrored thumbnail.
+	if typ == JPEG && o.wantAutoRotate() {
+		return nil, errors.New("pure-Go backend: AutoRotate is not supported; set Options.AutoRotate to a pointer to false")
+	}
+
+	var img image.Image
+	var err error
+
+	switch typ {
+	case JPEG:
+		img, err = jpeg.Decode(bytes.NewReader(buf))
+	case PNG:
+		img, err = png.Decode(bytes.NewReader(buf))
+	default:
+		return nil, errors.New("pure-Go backend: unsupported image format")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Type == UNKNOWN {
+		o.Type = typ
+	}
+	if o.Quality == 0 {
+		o.Quality = 100
+	}
+
+	b := img.Bounds()
+	factor, _, _ := computeShrinkFactor(b.Dx(), b.Dy(), &o)
+
+	// Fit the source into the o.Width x o.Height box preserving aspect
+	// ratio; when cropping, this deliberately overshoots one axis so the
+	// crop below has something to trim.
+	outW := int(math.Round(float64(b.Dx()) / factor))
+	outH := int(math.Round(float64(b.Dy()) / factor))
+
+	resized := resizeImage(img, outW, outH)
+
+	if o.Crop && (outW > o.Width || outH > o.Height) {
+		left, top := sharpCalcCrop(outW, outH, o.Width, o.Height, o.Gravity)
+		resized = resized.SubImage(image.Rect(left, top, left+o.Width, top+o.Height)).(*image.RGBA)
+	}
+
+	var out bytes.Buffer
+	switch o.Type {
+	case PNG:
+		err = png.Encode(&out, resized)
+	default:
+		// image/jpeg has no alpha channel and would otherwise drop it
+		// outright, leaving transparent regions as whatever raw colour was
+		// underneath; flatten onto white first, matching the vips backend's
+		// vips_flatten_0 behaviour before its own JPEG save.
+		err = jpeg.Encode(&out, flattenOnWhite(resized), &jpeg.Options{Quality: o.Quality})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// flattenOnWhite composites src over an opaque white background, returning
+// src unchanged (as an *image.RGBA) if it carries no alpha worth flattening.
+func flattenOnWhite(src *image.RGBA) *image.RGBA {
+	if !hasAlpha(src) {
+		return src
+	}
+
+	dst := image.NewRGBA(src.Bounds())
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Over)
+	return dst
+}
+
+func hasAlpha(src *image.RGBA) bool {
+	b := src.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if src.RGBAAt(x, y).A != 255 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (goBackend) Metadata(buf []byte) (ImageMetadata, error) {
+	typ := detectImageType(buf)
+
+	var cfg image.Config
+	var err error
+
+	switch typ {
+	case JPEG:
+		cfg, err = jpeg.DecodeConfig(bytes.NewReader(buf))
+	case PNG:
+		cfg, err = png.DecodeConfig(bytes.NewReader(buf))
+	default:
+		return ImageMetadata{}, errors.New("pure-Go backend: unsupported image format")
+	}
+	if err != nil {
+		return ImageMetadata{}, err
+	}
+
+	return ImageMetadata{
+		Width:  cfg.Width,
+		Height: cfg.Height,
+		Type:   typ,
+	}, nil
+}
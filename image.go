@@ -0,0 +1,68 @@
+package vips
+
+// Image wraps an in-memory image buffer and lets callers chain a sequence
+// of transforms without re-threading the buffer through each call by hand.
+// Each method re-encodes the buffer and, on success, keeps the result as
+// the new buffer so later calls operate on it.
+type Image struct {
+	buffer []byte
+}
+
+// NewImage wraps buf for chained processing. buf is not copied.
+func NewImage(buf []byte) *Image {
+	return &Image{buffer: buf}
+}
+
+// Buffer returns the image's current encoded bytes.
+func (i *Image) Buffer() []byte {
+	return i.buffer
+}
+
+// Process runs o against the current buffer, replacing it with the result.
+// It goes through the active Backend, so it keeps working under the
+// pure-Go fallback if libvips failed to initialize.
+func (i *Image) Process(o Options) ([]byte, error) {
+	buf, err := defaultBackend.Resize(i.buffer, o)
+	if err != nil {
+		return nil, err
+	}
+
+	i.buffer = buf
+	return i.buffer, nil
+}
+
+// Resize scales the image to fit within w x h.
+func (i *Image) Resize(w, h int) ([]byte, error) {
+	return i.Process(Options{Width: w, Height: h})
+}
+
+// Extract pulls out the w x h sub-region starting at (left, top).
+func (i *Image) Extract(top, left, w, h int) ([]byte, error) {
+	return i.Process(Options{AreaWidth: w, AreaHeight: h, Top: top, Left: left})
+}
+
+// Rotate applies a fixed-angle rotation.
+func (i *Image) Rotate(a Angle) ([]byte, error) {
+	return i.Process(Options{Rotate: a})
+}
+
+// Flip mirrors the image top-to-bottom.
+func (i *Image) Flip() ([]byte, error) {
+	return i.Process(Options{Flip: true})
+}
+
+// Flop mirrors the image left-to-right.
+func (i *Image) Flop() ([]byte, error) {
+	return i.Process(Options{Flop: true})
+}
+
+// Convert re-encodes the image as t, leaving its dimensions untouched.
+func (i *Image) Convert(t ImageType) ([]byte, error) {
+	return i.Process(Options{Type: t})
+}
+
+// Metadata inspects the image's current buffer via the active Backend; see
+// the package-level Metadata function for details.
+func (i *Image) Metadata() (ImageMetadata, error) {
+	return defaultBackend.Metadata(i.buffer)
+}
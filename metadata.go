@@ -0,0 +1,39 @@
+package vips
+
+/*
+#cgo pkg-config: vips
+#include "vips.h"
+*/
+import "C"
+
+// Metadata inspects buf's header and returns its dimensions, channel count,
+// format, colour space and alpha presence. Unlike Resize, this never runs a
+// transform pipeline, so it stays cheap even for very large source images.
+// Like Resize, anything not recognised as JPEG, PNG, WebP or TIFF is handed
+// to the generic ImageMagick loader rather than rejected outright.
+func Metadata(buf []byte) (ImageMetadata, error) {
+	typ := detectImageType(buf)
+
+	image, err := loadImage(buf, typ)
+	if err != nil {
+		return ImageMetadata{}, err
+	}
+
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+	defer C.g_object_unref(C.gpointer(image))
+
+	m := ImageMetadata{
+		Width:       int(image.Xsize),
+		Height:      int(image.Ysize),
+		Channels:    int(image.Bands),
+		Type:        typ,
+		Colorspace:  C.GoString(C.vips_enum_nick(C.VIPS_TYPE_INTERPRETATION, C.int(image.Type))),
+		Alpha:       C.vips_image_hasalpha(image) != 0,
+		Orientation: readOrientation(image),
+	}
+
+	return m, nil
+}
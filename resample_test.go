@@ -0,0 +1,103 @@
+package vips
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestKernelForPicksByDirection(t *testing.T) {
+	if got := kernelFor(1000, 500); got.support != lanczos3.support {
+		t.Fatalf("kernelFor(downscale) support = %v, want lanczos3's %v", got.support, lanczos3.support)
+	}
+	if got := kernelFor(500, 1000); got.support != catmullRom.support {
+		t.Fatalf("kernelFor(upscale) support = %v, want catmullRom's %v", got.support, catmullRom.support)
+	}
+	if got := kernelFor(500, 500); got.support != catmullRom.support {
+		t.Fatalf("kernelFor(unchanged) support = %v, want catmullRom's %v", got.support, catmullRom.support)
+	}
+}
+
+func TestAxisWeightsCountAndNormalization(t *testing.T) {
+	weights := axisWeights(100, 10, lanczos3)
+
+	if len(weights) != 10 {
+		t.Fatalf("axisWeights returned %d rows, want 10", len(weights))
+	}
+
+	for i, row := range weights {
+		if len(row) == 0 {
+			t.Fatalf("row %d has no contributing input pixels", i)
+		}
+
+		var total float64
+		for _, w := range row {
+			if w.index < 0 || w.index >= 100 {
+				t.Fatalf("row %d references out-of-range input index %d", i, w.index)
+			}
+			total += w.value
+		}
+		if math.Abs(total-1) > 1e-9 {
+			t.Fatalf("row %d weights sum to %v, want 1", i, total)
+		}
+	}
+}
+
+func TestAxisWeightsIdentityIsPassthrough(t *testing.T) {
+	weights := axisWeights(5, 5, catmullRom)
+
+	for i, row := range weights {
+		if len(row) != 1 || row[0].index != i || math.Abs(row[0].value-1) > 1e-9 {
+			t.Fatalf("row %d = %+v, want a single unit weight at index %d", i, row, i)
+		}
+	}
+}
+
+func TestResizeImageDimensions(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	out := resizeImage(src, 10, 8)
+
+	if b := out.Bounds(); b.Dx() != 10 || b.Dy() != 8 {
+		t.Fatalf("resizeImage output bounds = %v, want 10x8", b)
+	}
+}
+
+func TestResizeImageUniformColorIsPreserved(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	fill := color.RGBA{R: 10, G: 20, B: 30, A: 255}
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			src.SetRGBA(x, y, fill)
+		}
+	}
+
+	out := resizeImage(src, 4, 4)
+
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if got := out.RGBAAt(x, y); got != fill {
+				t.Fatalf("pixel (%d,%d) = %+v, want uniform fill %+v", x, y, got, fill)
+			}
+		}
+	}
+}
+
+func TestClamp8(t *testing.T) {
+	cases := []struct {
+		in   float64
+		want uint8
+	}{
+		{-10, 0},
+		{0, 0},
+		{127.6, 128},
+		{255, 255},
+		{300, 255},
+	}
+
+	for _, c := range cases {
+		if got := clamp8(c.in); got != c.want {
+			t.Fatalf("clamp8(%v) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Package thumbnailer turns the vips module into a thumbnail server
+// backend: given a configured set of target sizes it serves pre-generated
+// thumbnails from a Store and, when allowed, generates missing ones on the
+// fly from whichever existing size is the best fit.
+package thumbnailer
+
+// Method selects how a thumbnail's source image is fitted into its target
+// dimensions.
+type Method int
+
+const (
+	// Scale fits the source inside Width x Height, preserving its aspect
+	// ratio (the vips Options.Crop=false path).
+	Scale Method = iota
+	// Crop fills Width x Height exactly, cropping any excess (the vips
+	// Options.Crop=true path).
+	Crop
+)
+
+// Size is a single entry in a Config's target size set.
+type Size struct {
+	Width  int
+	Height int
+	Method Method
+}
+
+// Config describes the size set a Thumbnailer serves for every image, and
+// how aggressively it is allowed to generate sizes outside that set.
+type Config struct {
+	Sizes []Size
+
+	// DynamicThumbnails allows serving sizes outside Sizes by generating
+	// them on request. When false only the configured Sizes are ever
+	// produced, and requests for other dimensions fall back to the closest
+	// match.
+	DynamicThumbnails bool
+
+	// MaxParallelGenerators bounds how many generations may run at once;
+	// requests beyond that fall back rather than queue.
+	MaxParallelGenerators int
+}
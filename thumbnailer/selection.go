@@ -0,0 +1,69 @@
+package thumbnailer
+
+import "math"
+
+// candidate is an available source image we could generate a new thumbnail
+// from: either one of the configured Sizes, or the original.
+type candidate struct {
+	size   Size
+	buffer []byte
+}
+
+// fitness scores how good a candidate source is for producing a thumbnail
+// of the requested size. Lower is better in every field, and fields are
+// compared in order: a candidate only loses on aspectDistance or areaRatio
+// if it ties the previous field.
+type fitness struct {
+	undersized     int     // 0 if candidate covers the request in both dimensions, 1 otherwise
+	aspectDistance float64 // |log(candidateAR / requestAR)|
+	areaRatio      float64 // candidate area / requested area; penalises gratuitous upscaling
+}
+
+func scoreCandidate(c candidate, want Size) fitness {
+	if c.size.Width <= 0 || c.size.Height <= 0 || want.Width <= 0 || want.Height <= 0 {
+		return fitness{undersized: 1, aspectDistance: math.Inf(1), areaRatio: math.Inf(1)}
+	}
+
+	f := fitness{}
+
+	if c.size.Width < want.Width || c.size.Height < want.Height {
+		f.undersized = 1
+	}
+
+	candidateAR := float64(c.size.Width) / float64(c.size.Height)
+	wantAR := float64(want.Width) / float64(want.Height)
+	f.aspectDistance = math.Abs(math.Log(candidateAR / wantAR))
+
+	f.areaRatio = float64(c.size.Width*c.size.Height) / float64(want.Width*want.Height)
+
+	return f
+}
+
+func (f fitness) less(o fitness) bool {
+	if f.undersized != o.undersized {
+		return f.undersized < o.undersized
+	}
+
+	if f.aspectDistance != o.aspectDistance {
+		return f.aspectDistance < o.aspectDistance
+	}
+
+	return f.areaRatio < o.areaRatio
+}
+
+// bestCandidate returns the index of the candidate in candidates best
+// suited to derive a thumbnail of size want, or -1 if candidates is empty.
+func bestCandidate(candidates []candidate, want Size) int {
+	best := -1
+	var bestFitness fitness
+
+	for i, c := range candidates {
+		f := scoreCandidate(c, want)
+		if best == -1 || f.less(bestFitness) {
+			best = i
+			bestFitness = f
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,74 @@
+package thumbnailer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScoreCandidatePrefersNonUndersized(t *testing.T) {
+	want := Size{Width: 200, Height: 200}
+
+	undersized := scoreCandidate(candidate{size: Size{Width: 100, Height: 100}}, want)
+	adequate := scoreCandidate(candidate{size: Size{Width: 400, Height: 400}}, want)
+
+	if !adequate.less(undersized) {
+		t.Fatalf("expected adequate candidate to beat undersized one: adequate=%+v undersized=%+v", adequate, undersized)
+	}
+}
+
+func TestScoreCandidatePrefersCloserAspectRatio(t *testing.T) {
+	want := Size{Width: 200, Height: 100} // 2:1
+
+	square := scoreCandidate(candidate{size: Size{Width: 400, Height: 400}}, want) // 1:1
+	wide := scoreCandidate(candidate{size: Size{Width: 800, Height: 400}}, want)   // 2:1, exact match
+
+	if !wide.less(square) {
+		t.Fatalf("expected closer-aspect candidate to win: wide=%+v square=%+v", wide, square)
+	}
+	if wide.aspectDistance != 0 {
+		t.Fatalf("expected zero aspect distance for an exact match, got %v", wide.aspectDistance)
+	}
+}
+
+func TestScoreCandidatePrefersSmallerAreaOnTie(t *testing.T) {
+	want := Size{Width: 200, Height: 200}
+
+	small := scoreCandidate(candidate{size: Size{Width: 400, Height: 400}}, want)
+	large := scoreCandidate(candidate{size: Size{Width: 800, Height: 800}}, want)
+
+	if small.aspectDistance != large.aspectDistance {
+		t.Fatalf("expected equal aspect distance for same-ratio candidates, got %v vs %v", small.aspectDistance, large.aspectDistance)
+	}
+	if !small.less(large) {
+		t.Fatalf("expected smaller-area candidate to win on tie: small=%+v large=%+v", small, large)
+	}
+}
+
+func TestScoreCandidateDegenerateSizeIsWorstPossible(t *testing.T) {
+	f := scoreCandidate(candidate{size: Size{Width: 0, Height: 0}}, Size{Width: 100, Height: 100})
+
+	if f.undersized != 1 || !math.IsInf(f.aspectDistance, 1) || !math.IsInf(f.areaRatio, 1) {
+		t.Fatalf("expected worst-possible fitness for a degenerate size, got %+v", f)
+	}
+}
+
+func TestBestCandidateEmpty(t *testing.T) {
+	if got := bestCandidate(nil, Size{Width: 100, Height: 100}); got != -1 {
+		t.Fatalf("bestCandidate(nil, ...) = %d, want -1", got)
+	}
+}
+
+func TestBestCandidatePicksFittestAmongMany(t *testing.T) {
+	want := Size{Width: 200, Height: 200}
+	candidates := []candidate{
+		{size: Size{Width: 50, Height: 50}},     // undersized
+		{size: Size{Width: 1000, Height: 10}},   // wrong aspect ratio
+		{size: Size{Width: 256, Height: 256}},   // good fit
+		{size: Size{Width: 2000, Height: 2000}}, // same aspect, much larger
+	}
+
+	best := bestCandidate(candidates, want)
+	if best != 2 {
+		t.Fatalf("bestCandidate = %d, want 2 (the 256x256 candidate)", best)
+	}
+}
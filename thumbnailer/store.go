@@ -0,0 +1,14 @@
+package thumbnailer
+
+// Store persists and retrieves generated thumbnails and the original image
+// they were derived from. Implementations are free to back this with a
+// filesystem, object store, etc.
+type Store interface {
+	// Original returns the source image for id.
+	Original(id string) ([]byte, error)
+	// Get returns the previously generated thumbnail for id at size, and
+	// false if none has been generated yet.
+	Get(id string, size Size) ([]byte, bool, error)
+	// Put persists a newly generated thumbnail for id at size.
+	Put(id string, size Size, buf []byte) error
+}
@@ -0,0 +1,180 @@
+package thumbnailer
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/zmack/vips"
+)
+
+// Thumbnailer serves thumbnails for a fixed Config against a Store,
+// generating missing dynamic sizes on demand while bounding how many
+// generations run concurrently.
+type Thumbnailer struct {
+	cfg   Config
+	store Store
+
+	sem chan struct{}
+
+	mu       sync.Mutex
+	inFlight map[string]chan struct{}
+}
+
+// New builds a Thumbnailer for cfg backed by store. A MaxParallelGenerators
+// of 0 or less is treated as 1.
+func New(cfg Config, store Store) *Thumbnailer {
+	max := cfg.MaxParallelGenerators
+	if max <= 0 {
+		max = 1
+	}
+
+	return &Thumbnailer{
+		cfg:      cfg,
+		store:    store,
+		sem:      make(chan struct{}, max),
+		inFlight: make(map[string]chan struct{}),
+	}
+}
+
+// Get returns a thumbnail for id at w x h using method, generating it if
+// necessary and allowed. When generation is not possible right now (the
+// generator pool is full, or dynamic thumbnails are disabled), it falls
+// back to the closest size already available rather than blocking.
+func (t *Thumbnailer) Get(id string, w, h int, method Method) ([]byte, error) {
+	want := Size{Width: w, Height: h, Method: method}
+
+	if buf, ok, err := t.store.Get(id, want); err != nil {
+		return nil, err
+	} else if ok {
+		return buf, nil
+	}
+
+	if !t.isConfiguredSize(want) && !t.cfg.DynamicThumbnails {
+		return t.fallback(id, want)
+	}
+
+	return t.getOrGenerate(id, want)
+}
+
+func (t *Thumbnailer) isConfiguredSize(want Size) bool {
+	for _, s := range t.cfg.Sizes {
+		if s == want {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getOrGenerate deduplicates concurrent identical requests via inFlight,
+// and falls back instead of generating when the generator pool is full.
+func (t *Thumbnailer) getOrGenerate(id string, want Size) ([]byte, error) {
+	key := fmt.Sprintf("%s:%dx%d:%d", id, want.Width, want.Height, want.Method)
+
+	t.mu.Lock()
+	if done, ok := t.inFlight[key]; ok {
+		t.mu.Unlock()
+		<-done
+		if buf, ok, err := t.store.Get(id, want); err == nil && ok {
+			return buf, nil
+		}
+		return t.fallback(id, want)
+	}
+
+	done := make(chan struct{})
+	t.inFlight[key] = done
+	t.mu.Unlock()
+
+	defer func() {
+		t.mu.Lock()
+		delete(t.inFlight, key)
+		t.mu.Unlock()
+		close(done)
+	}()
+
+	select {
+	case t.sem <- struct{}{}:
+		defer func() { <-t.sem }()
+	default:
+		// Generator pool is saturated; don't make this request wait on it.
+		return t.fallback(id, want)
+	}
+
+	return t.generate(id, want)
+}
+
+func (t *Thumbnailer) generate(id string, want Size) ([]byte, error) {
+	candidates, err := t.candidates(id)
+	if err != nil {
+		return nil, err
+	}
+
+	best := bestCandidate(candidates, want)
+	if best == -1 {
+		return nil, fmt.Errorf("thumbnailer: no source available to generate %dx%d for %q", want.Width, want.Height, id)
+	}
+
+	buf, err := vips.NewImage(candidates[best].buffer).Process(vips.Options{
+		Width:  want.Width,
+		Height: want.Height,
+		Crop:   want.Method == Crop,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.store.Put(id, want, buf); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// candidates gathers every source we could derive a new thumbnail from:
+// the original, plus whichever configured sizes already exist for id.
+func (t *Thumbnailer) candidates(id string) ([]candidate, error) {
+	var candidates []candidate
+
+	original, err := t.store.Original(id)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := vips.NewImage(original).Metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates = append(candidates, candidate{
+		size:   Size{Width: meta.Width, Height: meta.Height},
+		buffer: original,
+	})
+
+	for _, s := range t.cfg.Sizes {
+		buf, ok, err := t.store.Get(id, s)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			candidates = append(candidates, candidate{size: s, buffer: buf})
+		}
+	}
+
+	return candidates, nil
+}
+
+// fallback serves the closest pre-generated size (or the original) for
+// want, without generating anything new.
+func (t *Thumbnailer) fallback(id string, want Size) ([]byte, error) {
+	candidates, err := t.candidates(id)
+	if err != nil {
+		return nil, err
+	}
+
+	best := bestCandidate(candidates, want)
+	if best == -1 {
+		return nil, fmt.Errorf("thumbnailer: no thumbnail available for %q", id)
+	}
+
+	return candidates[best].buffer, nil
+}
@@ -0,0 +1,148 @@
+package thumbnailer
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zmack/vips"
+)
+
+// fakeStore is an in-memory Store used to exercise Thumbnailer without
+// touching disk or a real image backend.
+type fakeStore struct {
+	mu        sync.Mutex
+	originals map[string][]byte
+	thumbs    map[string]map[Size][]byte
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		originals: make(map[string][]byte),
+		thumbs:    make(map[string]map[Size][]byte),
+	}
+}
+
+func (s *fakeStore) Original(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.originals[id], nil
+}
+
+func (s *fakeStore) Get(id string, size Size) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buf, ok := s.thumbs[id][size]
+	return buf, ok, nil
+}
+
+func (s *fakeStore) Put(id string, size Size, buf []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.thumbs[id] == nil {
+		s.thumbs[id] = make(map[Size][]byte)
+	}
+	s.thumbs[id][size] = buf
+	return nil
+}
+
+// slowCountingBackend counts how many Resize calls it serves and optionally
+// blocks on a channel so tests can control exactly how long a generation
+// takes relative to concurrent requests.
+type slowCountingBackend struct {
+	calls int32
+	block <-chan struct{}
+}
+
+func (b *slowCountingBackend) Resize(buf []byte, o vips.Options) ([]byte, error) {
+	atomic.AddInt32(&b.calls, 1)
+	if b.block != nil {
+		<-b.block
+	}
+	return buf, nil
+}
+
+func (b *slowCountingBackend) Metadata(buf []byte) (vips.ImageMetadata, error) {
+	return vips.ImageMetadata{Width: 1000, Height: 1000}, nil
+}
+
+func TestGetOrGenerateDeduplicatesConcurrentRequests(t *testing.T) {
+	store := newFakeStore()
+	store.originals["img"] = []byte("original")
+
+	block := make(chan struct{})
+	backend := &slowCountingBackend{block: block}
+	vips.SetBackend(backend)
+
+	th := New(Config{MaxParallelGenerators: 4, DynamicThumbnails: true}, store)
+	want := Size{Width: 100, Height: 100}
+
+	var wg sync.WaitGroup
+	results := make([][]byte, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			buf, err := th.Get("img", want.Width, want.Height, want.Method)
+			if err != nil {
+				t.Errorf("Get returned error: %v", err)
+			}
+			results[i] = buf
+		}(i)
+	}
+
+	// Give the goroutines a chance to pile up behind the in-flight generation.
+	time.Sleep(20 * time.Millisecond)
+	close(block)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&backend.calls); got != 1 {
+		t.Fatalf("backend.Resize called %d times, want 1 (requests should dedup)", got)
+	}
+	for i, buf := range results {
+		if string(buf) != "original" {
+			t.Fatalf("result[%d] = %q, want the generated thumbnail", i, buf)
+		}
+	}
+}
+
+func TestGetOrGenerateFallsBackWhenPoolSaturated(t *testing.T) {
+	store := newFakeStore()
+	store.originals["img"] = []byte("original")
+
+	block := make(chan struct{})
+	defer close(block)
+	backend := &slowCountingBackend{block: block}
+	vips.SetBackend(backend)
+
+	th := New(Config{MaxParallelGenerators: 1, DynamicThumbnails: true}, store)
+
+	// Occupy the single generator slot with a request that never returns
+	// until we close `block`.
+	go th.Get("img", 100, 100, Scale)
+	time.Sleep(20 * time.Millisecond)
+
+	// A second, differently-sized request must not block behind the first;
+	// it should fall back to the best available candidate (the original).
+	done := make(chan struct{})
+	var buf []byte
+	var err error
+	go func() {
+		buf, err = th.Get("img", 200, 200, Scale)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Get did not return promptly; expected a fallback instead of blocking on the saturated pool")
+	}
+
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if string(buf) != "original" {
+		t.Fatalf("Get = %q, want fallback to the original", buf)
+	}
+}
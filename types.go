@@ -0,0 +1,221 @@
+package vips
+
+import (
+	"bytes"
+	"math"
+)
+
+// This file holds the declarations shared by both the libvips-backed and
+// pure-Go backends. It must build without cgo so the package still exposes
+// a usable API (Options, ImageType, ImageMetadata, ...) when compiled with
+// CGO_ENABLED=0 or without libvips installed; see backend_cgo.go and
+// backend_nocgo.go for the pieces that do depend on a backend.
+
+var (
+	MARKER_JPEG    = []byte{0xff, 0xd8}
+	MARKER_PNG     = []byte{0x89, 0x50}
+	MARKER_WEBP    = []byte{0x52, 0x49, 0x46, 0x46} // "RIFF", followed at offset 8 by "WEBP"
+	MARKER_TIFF_LE = []byte{0x49, 0x49, 0x2a, 0x00} // "II*\0"
+	MARKER_TIFF_BE = []byte{0x4d, 0x4d, 0x00, 0x2a} // "MM\0*"
+)
+
+type ImageType int
+
+const (
+	UNKNOWN ImageType = iota
+	JPEG
+	PNG
+	WEBP
+	TIFF
+)
+
+// detectImageType sniffs buf's magic bytes and returns the ImageType it
+// matches, or UNKNOWN if none of the supported formats apply.
+func detectImageType(buf []byte) ImageType {
+	switch {
+	case len(buf) >= 2 && bytes.Equal(buf[:2], MARKER_JPEG):
+		return JPEG
+	case len(buf) >= 2 && bytes.Equal(buf[:2], MARKER_PNG):
+		return PNG
+	case len(buf) >= 12 && bytes.Equal(buf[:4], MARKER_WEBP) && bytes.Equal(buf[8:12], []byte("WEBP")):
+		return WEBP
+	case len(buf) >= 4 && (bytes.Equal(buf[:4], MARKER_TIFF_LE) || bytes.Equal(buf[:4], MARKER_TIFF_BE)):
+		return TIFF
+	default:
+		return UNKNOWN
+	}
+}
+
+type Interpolator int
+
+const (
+	BICUBIC Interpolator = iota
+	BILINEAR
+	NOHALO
+)
+
+var interpolations = map[Interpolator]string{
+	BICUBIC:  "bicubic",
+	BILINEAR: "bilinear",
+	NOHALO:   "nohalo",
+}
+
+func (i Interpolator) String() string { return interpolations[i] }
+
+type Extend int
+
+// These mirror libvips' VipsExtend enum ordinals directly, rather than
+// referencing C.VIPS_EXTEND_* constants, so this file can build without cgo.
+const (
+	EXTEND_BLACK Extend = 0
+	EXTEND_WHITE Extend = 4
+)
+
+type Angle int
+
+const (
+	D0   Angle = 0
+	D90  Angle = 90
+	D180 Angle = 180
+	D270 Angle = 270
+)
+
+type CropRect struct {
+	Top    uint
+	Left   uint
+	Width  uint
+	Height uint
+}
+
+type Options struct {
+	Height       int
+	Width        int
+	Crop         bool // Deprecated
+	CropRect     *CropRect
+	Enlarge      bool
+	Extend       Extend
+	Embed        bool
+	Interpolator Interpolator
+	BlurAmount   float32
+	Gravity      Gravity
+	Quality      int
+	Type         ImageType // target format for the save stage; defaults to the source format
+	Rotate       Angle
+	Flip         bool
+	Flop         bool
+	AreaWidth    int // extract-area primitive, applied before rotate/flip
+	AreaHeight   int
+	Top          int
+	Left         int
+	Zoom         int
+	// AutoRotate applies the EXIF orientation tag, if any, before any other
+	// transform runs, then strips it from the output. nil (the zero value)
+	// means "on", matching CropRect's nil-means-unset convention.
+	AutoRotate *bool
+}
+
+// wantAutoRotate reports whether o asks for the EXIF orientation tag to be
+// applied; nil means on, matching CropRect's nil-means-unset convention.
+func (o Options) wantAutoRotate() bool {
+	return o.AutoRotate == nil || *o.AutoRotate
+}
+
+// ImageMetadata describes the header of an image without requiring a full
+// decode of its pixel data.
+type ImageMetadata struct {
+	Width       int
+	Height      int
+	Channels    int
+	Type        ImageType
+	Colorspace  string
+	Alpha       bool
+	Orientation int // raw EXIF orientation (1-8), 0 if absent
+}
+
+type Gravity int
+
+const (
+	CENTRE Gravity = 1 << iota
+	NORTH
+	EAST
+	SOUTH
+	WEST
+)
+
+// sharpCalcCrop works out the top-left offset to crop an inWidth x inHeight
+// image down to outWidth x outHeight according to gravity, defaulting to a
+// centred crop.
+func sharpCalcCrop(inWidth, inHeight, outWidth, outHeight int, gravity Gravity) (int, int) {
+	left := (inWidth - outWidth + 1) / 2
+	top := (inHeight - outHeight + 1) / 2
+
+	if (gravity & NORTH) != 0 {
+		top = 0
+	}
+
+	if (gravity & EAST) != 0 {
+		left = inWidth - outWidth
+	}
+
+	if (gravity & SOUTH) != 0 {
+		top = inHeight - outHeight
+	}
+
+	if (gravity & WEST) != 0 {
+		left = 0
+	}
+
+	return left, top
+}
+
+// computeShrinkFactor works out the overall scale factor needed to take an
+// inWidth x inHeight image to o.Width x o.Height, splitting it into an
+// integral shrink (for vips_shrink) and a residual (for vips_affine). It
+// also fills in whichever of o.Width/o.Height was left at 0 for an
+// aspect-preserving resize, and disables the whole transform (factor 1,
+// shrink 1, residual 0) when !o.Enlarge and the source is already smaller
+// than the request.
+func computeShrinkFactor(inWidth, inHeight int, o *Options) (factor float64, shrink int, residual float64) {
+	switch {
+	// Fixed width and height
+	case o.Width > 0 && o.Height > 0:
+		xf := float64(inWidth) / float64(o.Width)
+		yf := float64(inHeight) / float64(o.Height)
+		if o.Crop {
+			factor = math.Min(xf, yf)
+		} else {
+			factor = math.Max(xf, yf)
+		}
+	// Fixed width, auto height
+	case o.Width > 0:
+		factor = float64(inWidth) / float64(o.Width)
+		o.Height = int(math.Floor(float64(inHeight) / factor))
+	// Fixed height, auto width
+	case o.Height > 0:
+		factor = float64(inHeight) / float64(o.Height)
+		o.Width = int(math.Floor(float64(inWidth) / factor))
+	// Identity transform
+	default:
+		factor = 1
+		o.Width = inWidth
+		o.Height = inHeight
+	}
+
+	shrink = int(math.Floor(factor))
+	if shrink < 1 {
+		shrink = 1
+	}
+
+	residual = float64(shrink) / factor
+
+	// Do not enlarge the output if the input width *or* height are already less than the required dimensions
+	if !o.Enlarge && inWidth < o.Width && inHeight < o.Height {
+		factor = 1
+		shrink = 1
+		residual = 0
+		o.Width = inWidth
+		o.Height = inHeight
+	}
+
+	return factor, shrink, residual
+}
@@ -7,72 +7,15 @@ package vips
 import "C"
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
+	"log"
 	"math"
 	"runtime"
 	"strings"
 	"unsafe"
 )
 
-var (
-	MARKER_JPEG = []byte{0xff, 0xd8}
-	MARKER_PNG  = []byte{0x89, 0x50}
-)
-
-type ImageType int
-
-const (
-	UNKNOWN ImageType = iota
-	JPEG
-	PNG
-)
-
-type Interpolator int
-
-const (
-	BICUBIC Interpolator = iota
-	BILINEAR
-	NOHALO
-)
-
-type Extend int
-
-const (
-	EXTEND_BLACK Extend = C.VIPS_EXTEND_BLACK
-	EXTEND_WHITE Extend = C.VIPS_EXTEND_WHITE
-)
-
-var interpolations = map[Interpolator]string{
-	BICUBIC:  "bicubic",
-	BILINEAR: "bilinear",
-	NOHALO:   "nohalo",
-}
-
-func (i Interpolator) String() string { return interpolations[i] }
-
-type CropRect struct {
-	Top    uint
-	Left   uint
-	Width  uint
-	Height uint
-}
-
-type Options struct {
-	Height       int
-	Width        int
-	Crop         bool // Deprecated
-	CropRect     *CropRect
-	Enlarge      bool
-	Extend       Extend
-	Embed        bool
-	Interpolator Interpolator
-	BlurAmount   float32
-	Gravity      Gravity
-	Quality      int
-}
-
 type VipsImagePtr *C.struct__VipsImage
 
 func init() {
@@ -91,7 +34,9 @@ func Initialize() {
 
 	if err := C.vips_initialize(); err != 0 {
 		C.vips_shutdown()
-		panic("unable to start vips!")
+		log.Printf("vips: libvips failed to initialize (code %d), falling back to the pure-Go backend", err)
+		SetBackend(goBackend{})
+		return
 	}
 
 	C.vips_concurrency_set(1)
@@ -115,6 +60,20 @@ func Debug() {
 	C.im__print_all()
 }
 
+// vipsVersionAtLeast reports whether the linked libvips is at least
+// major.minor, using the runtime vips_version() query rather than the
+// headers it was built against.
+func vipsVersionAtLeast(major, minor int) bool {
+	gotMajor := int(C.vips_version(0))
+	gotMinor := int(C.vips_version(1))
+
+	if gotMajor != major {
+		return gotMajor > major
+	}
+
+	return gotMinor >= minor
+}
+
 func Crop(image VipsImagePtr, top uint, left uint, width uint, height uint) (VipsImagePtr, error) {
 	var outImage *C.struct__VipsImage
 
@@ -132,6 +91,169 @@ func Crop(image VipsImagePtr, top uint, left uint, width uint, height uint) (Vip
 	}
 }
 
+// vipsOpError builds an error from the current vips error buffer, in the
+// style of Crop's inline handling, for the transform helpers below.
+func vipsOpError(op string, image VipsImagePtr) error {
+	C.vips_error_freeze()
+	errStr := C.GoString(C.vips_error_buffer())
+	errStr = strings.TrimRight(errStr, " \n")
+	C.vips_error_clear()
+	C.vips_error_thaw()
+	C.g_object_unref(C.gpointer(image))
+	return errors.New(fmt.Sprintf("Could not %s image: %s", op, errStr))
+}
+
+// extractOrEmbedImage applies the AreaWidth/AreaHeight/Top/Left extract-area
+// primitive, pulling out the requested sub-region before any other
+// transform runs. When no area is set it returns image unchanged.
+func extractOrEmbedImage(image VipsImagePtr, o Options) (VipsImagePtr, error) {
+	if o.AreaWidth <= 0 || o.AreaHeight <= 0 {
+		return image, nil
+	}
+
+	var outImage *C.struct__VipsImage
+
+	C.vips_extract_area_(image, &outImage, C.int(o.Left), C.int(o.Top), C.int(o.AreaWidth), C.int(o.AreaHeight))
+
+	if outImage == nil {
+		return nil, vipsOpError("extract area from", image)
+	}
+
+	C.g_object_unref(C.gpointer(image))
+	return VipsImagePtr(outImage), nil
+}
+
+// rotateAndFlipImage applies the Rotate angle followed by the Flip/Flop
+// mirror operations, in that order, returning image unchanged if none of
+// the three are set.
+func rotateAndFlipImage(image VipsImagePtr, o Options) (VipsImagePtr, error) {
+	if o.Rotate != D0 {
+		var outImage *C.struct__VipsImage
+
+		var angle C.VipsAngle
+		switch o.Rotate {
+		case D90:
+			angle = C.VIPS_ANGLE_D90
+		case D180:
+			angle = C.VIPS_ANGLE_D180
+		case D270:
+			angle = C.VIPS_ANGLE_D270
+		default:
+			angle = C.VIPS_ANGLE_D0
+		}
+
+		C.vips_rot_(image, &outImage, angle)
+
+		if outImage == nil {
+			return nil, vipsOpError("rotate", image)
+		}
+
+		C.g_object_unref(C.gpointer(image))
+		image = VipsImagePtr(outImage)
+	}
+
+	if o.Flip {
+		var outImage *C.struct__VipsImage
+
+		C.vips_flip_(image, &outImage, C.VIPS_DIRECTION_VERTICAL)
+
+		if outImage == nil {
+			return nil, vipsOpError("flip", image)
+		}
+
+		C.g_object_unref(C.gpointer(image))
+		image = VipsImagePtr(outImage)
+	}
+
+	if o.Flop {
+		var outImage *C.struct__VipsImage
+
+		C.vips_flip_(image, &outImage, C.VIPS_DIRECTION_HORIZONTAL)
+
+		if outImage == nil {
+			return nil, vipsOpError("flop", image)
+		}
+
+		C.g_object_unref(C.gpointer(image))
+		image = VipsImagePtr(outImage)
+	}
+
+	return image, nil
+}
+
+// zoomImage pixel-replicates image by the given integral factor. A zoom of
+// 0 or 1 is a no-op.
+func zoomImage(image VipsImagePtr, zoom int) (VipsImagePtr, error) {
+	if zoom <= 1 {
+		return image, nil
+	}
+
+	var outImage *C.struct__VipsImage
+
+	C.vips_zoom_(image, &outImage, C.int(zoom), C.int(zoom))
+
+	if outImage == nil {
+		return nil, vipsOpError("zoom", image)
+	}
+
+	C.g_object_unref(C.gpointer(image))
+	return VipsImagePtr(outImage), nil
+}
+
+const exifOrientationField = "exif-ifd0-Orientation"
+
+// readOrientation returns the numeric EXIF orientation (1-8) embedded in
+// image, or 0 if the tag is absent or unparsable.
+func readOrientation(image VipsImagePtr) int {
+	name := C.CString(exifOrientationField)
+	defer C.free(unsafe.Pointer(name))
+
+	var value *C.char
+	if C.vips_image_get_string(image, name, &value) != 0 {
+		return 0
+	}
+
+	// The tag is stored as e.g. "6 (Rotate 90 CW)"; only the leading digit
+	// matters here.
+	orientation := 0
+	fmt.Sscanf(C.GoString(value), "%d", &orientation)
+	return orientation
+}
+
+// orientationToTransform maps an EXIF orientation value to the rotate/flip
+// combination that undoes it. Orientations 5 and 7 (transpose/transverse)
+// are approximated with a rotate+flip pair, same as orientations 2-8 cover
+// the eight standard cases.
+func orientationToTransform(orientation int) (angle Angle, flip bool, flop bool) {
+	switch orientation {
+	case 2:
+		return D0, false, true
+	case 3:
+		return D180, false, false
+	case 4:
+		return D0, true, false
+	case 5:
+		return D270, true, false
+	case 6:
+		return D90, false, false
+	case 7:
+		return D90, true, false
+	case 8:
+		return D270, false, false
+	default:
+		return D0, false, false
+	}
+}
+
+// stripOrientationTag removes the EXIF orientation field from image so that
+// a viewer doesn't apply the already-baked-in rotation a second time.
+func stripOrientationTag(image VipsImagePtr) {
+	name := C.CString(exifOrientationField)
+	defer C.free(unsafe.Pointer(name))
+
+	C.vips_image_remove(image, name)
+}
+
 func validCrop(image VipsImagePtr, crop *CropRect) bool {
 	if crop == nil {
 		return false
@@ -167,82 +289,63 @@ func validateCrop(image VipsImagePtr, crop *CropRect) *CropRect {
 	return crop
 }
 
-func ResizeMagick(buf []byte, o Options) ([]byte, error) {
-	var image, tmpImage *C.struct__VipsImage
+// loadImage feeds buf into the loader for typ, falling back to
+// vips_magickload_buffer_ for anything detectImageType couldn't identify by
+// magic bytes.
+func loadImage(buf []byte, typ ImageType) (VipsImagePtr, error) {
+	if len(buf) == 0 {
+		return nil, errors.New("empty image buffer")
+	}
 
-	C.vips_magickload_buffer_(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image)
+	var image *C.struct__VipsImage
 
-	// TODO: Consider not doing this on _every_ image
-	defer C.vips_thread_shutdown()
+	switch typ {
+	case JPEG:
+		C.vips_jpegload_buffer_seq(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image)
+	case PNG:
+		C.vips_pngload_buffer_seq(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image)
+	case WEBP:
+		C.vips_webpload_buffer_seq(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image)
+	case TIFF:
+		C.vips_tiffload_buffer_seq(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image)
+	default:
+		C.vips_magickload_buffer_(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image)
+	}
 
 	if image == nil {
 		return nil, errors.New("unknown image format")
 	}
 
-	cropRect := validateCrop(image, o.CropRect)
-
-	if cropRect != nil {
-		tmpImage, err := Crop(image, cropRect.Left, cropRect.Top, cropRect.Width, cropRect.Height)
-
-		if err != nil {
-			C.g_object_unref(C.gpointer(image))
-			return nil, err
-		}
+	return VipsImagePtr(image), nil
+}
 
-		C.g_object_unref(C.gpointer(image))
-		image = tmpImage
-	}
+// shrinkOnLoad reloads buf from scratch using typ's native shrink-on-load
+// loader (libjpeg for JPEG, libwebp for WebP from libvips 8.3 onwards),
+// producing an image already shrunk by the integral factor.
+func shrinkOnLoad(buf []byte, typ ImageType, factor int) (VipsImagePtr, error) {
+	var image *C.struct__VipsImage
+	var err C.int
 
-	// get WxH
-	inWidth := int(image.Xsize)
-	inHeight := int(image.Ysize)
-
-	// prepare for factor
-	factor := 0.0
-
-	switch {
-	// Fixed width and height
-	case o.Width > 0 && o.Height > 0:
-		xf := float64(inWidth) / float64(o.Width)
-		yf := float64(inHeight) / float64(o.Height)
-		factor = math.Max(xf, yf)
-	// Fixed width, auto height
-	case o.Width > 0:
-		factor = float64(inWidth) / float64(o.Width)
-		o.Height = int(math.Floor(float64(inHeight) / factor))
-	// Fixed height, auto width
-	case o.Height > 0:
-		factor = float64(inHeight) / float64(o.Height)
-		o.Width = int(math.Floor(float64(inWidth) / factor))
-	// Identity transform
+	switch typ {
+	case WEBP:
+		err = C.vips_webpload_buffer_shrink(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image, C.int(factor))
 	default:
-		factor = 1
-		o.Width = inWidth
-		o.Height = inHeight
+		err = C.vips_jpegload_buffer_shrink(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image, C.int(factor))
 	}
 
-	// shrink
-	shrink := int(math.Floor(factor))
-	if shrink < 1 {
-		shrink = 1
+	if err != 0 {
+		return nil, resizeError()
 	}
 
-	// residual
-	residual := float64(shrink) / factor
+	return VipsImagePtr(image), nil
+}
 
-	// Do not enlarge the output if the input width *or* height are already less than the required dimensions
-	if !o.Enlarge {
-		if inWidth < o.Width && inHeight < o.Height {
-			factor = 1
-			shrink = 1
-			residual = 0
-			o.Width = inWidth
-			o.Height = inHeight
-		}
-	}
+// transformImage applies the integral vips_shrink and, for the leftover
+// sub-pixel factor, a vips_affine pass using o.Interpolator.
+func transformImage(image VipsImagePtr, o Options, shrink int, residual float64) (VipsImagePtr, error) {
+	var tmpImage *C.struct__VipsImage
 
 	if shrink > 1 {
-		// Use vips_shrink with the integral reduction
 		err := C.vips_shrink_0(image, &tmpImage, C.double(float64(shrink)), C.double(float64(shrink)))
 		C.g_object_unref(C.gpointer(image))
 		image = tmpImage
@@ -251,21 +354,16 @@ func ResizeMagick(buf []byte, o Options) ([]byte, error) {
 		}
 
 		// Recalculate residual float based on dimensions of required vs shrunk images
-		shrunkWidth := int(image.Xsize)
-		shrunkHeight := int(image.Ysize)
-
-		residualx := float64(o.Width) / float64(shrunkWidth)
-		residualy := float64(o.Height) / float64(shrunkHeight)
+		residualx := float64(o.Width) / float64(image.Xsize)
+		residualy := float64(o.Height) / float64(image.Ysize)
 		residual = math.Min(residualx, residualy)
 	}
 
-	// Use vips_affine with the remaining float part
 	if residual != 0 {
 		// Create interpolator - "bilinear" (default), "bicubic" or "nohalo"
 		is := C.CString(o.Interpolator.String())
 		interpolator := C.vips_interpolate_new(is)
 
-		// Perform affine transformation
 		err := C.vips_affine_interpolator(image, &tmpImage, C.double(residual), 0, 0, C.double(residual), interpolator)
 		C.g_object_unref(C.gpointer(image))
 		C.g_object_unref(C.gpointer(interpolator))
@@ -276,21 +374,30 @@ func ResizeMagick(buf []byte, o Options) ([]byte, error) {
 		}
 	}
 
-	// Always flatten
-	if image.Bands > 3 {
-		if -1 != C.vips_flatten_0(image, &tmpImage) {
-			C.g_object_unref(C.gpointer(image))
-			image = tmpImage
-		}
-	}
+	return VipsImagePtr(image), nil
+}
 
-	// Always convert to sRGB colour space
+// saveImage flattens, converts to sRGB, applies blur, and encodes image as
+// o.Type (defaulting to JPEG), unreffing image in the process.
+func saveImage(image VipsImagePtr, o Options) ([]byte, error) {
+	var tmpImage *C.struct__VipsImage
+
+	// Switch to sRGB before we do anything else because flattening
+	// with some other colorspace will not bode well in most other cases
 	if -1 != C.vips_colourspace_0(image, &tmpImage, C.VIPS_INTERPRETATION_sRGB) {
 		C.g_object_unref(C.gpointer(image))
 		image = tmpImage
 	}
 
-	// Apply blur if needed
+	// Only flatten if we're not running CMYK and we're rocking
+	// more than the standard 3 bands
+	if image.Type != C.VIPS_INTERPRETATION_CMYK && image.Bands > 3 {
+		if -1 != C.vips_flatten_0(image, &tmpImage) {
+			C.g_object_unref(C.gpointer(image))
+			image = tmpImage
+		}
+	}
+
 	if o.BlurAmount > 0 {
 		if -1 != C.vips_gaussian_blur(image, &tmpImage, C.double(o.BlurAmount)) {
 			C.g_object_unref(C.gpointer(image))
@@ -298,48 +405,46 @@ func ResizeMagick(buf []byte, o Options) ([]byte, error) {
 		}
 	}
 
-	// Finally save
 	length := C.size_t(0)
 	var ptr unsafe.Pointer
 
-	C.vips_jpegsave_custom(image, &ptr, &length, 1, C.int(o.Quality), 0)
-	C.g_object_unref(C.gpointer(image))
+	switch o.Type {
+	case PNG:
+		C.vips_pngsave_custom(image, &ptr, &length, 1, C.int(o.Quality))
+	case WEBP:
+		C.vips_webpsave_custom(image, &ptr, &length, 1, C.int(o.Quality))
+	case TIFF:
+		C.vips_tiffsave_custom(image, &ptr, &length, 1, C.int(o.Quality))
+	default:
+		C.vips_jpegsave_custom(image, &ptr, &length, 1, C.int(o.Quality), 0)
+	}
 
-	// get back the buffer
-	buf = C.GoBytes(ptr, C.int(length))
+	C.g_object_unref(C.gpointer(image))
 
-	// cleanup
+	buf := C.GoBytes(ptr, C.int(length))
 	C.g_free(C.gpointer(ptr))
-	C.vips_error_clear()
 
 	return buf, nil
 }
 
+// Resize is a thin orchestrator over the load/transform/save stages above.
+// typ is detected from buf's magic bytes; anything not recognised as JPEG,
+// PNG, WebP or TIFF is handed to the generic ImageMagick loader.
 func Resize(buf []byte, o Options) ([]byte, error) {
-	// detect (if possible) the file type
-	typ := UNKNOWN
-	switch {
-	case bytes.Equal(buf[:2], MARKER_JPEG):
-		typ = JPEG
-	case bytes.Equal(buf[:2], MARKER_PNG):
-		typ = PNG
-	default:
-		return nil, errors.New("unknown image format")
-	}
+	typ := detectImageType(buf)
 
-	// create an image instance
-	var image, tmpImage *C.struct__VipsImage
+	// save to the source format unless the caller asked for a conversion
+	if o.Type == UNKNOWN {
+		o.Type = typ
+	}
 
-	// Do shrink on load by default, however
-	// don't do it in the case of cropped images
-	useShrinkOnLoad := true
+	if o.Quality == 0 {
+		o.Quality = 100
+	}
 
-	// feed it
-	switch typ {
-	case JPEG:
-		C.vips_jpegload_buffer_seq(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image)
-	case PNG:
-		C.vips_pngload_buffer_seq(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image)
+	image, err := loadImage(buf, typ)
+	if err != nil {
+		return nil, err
 	}
 
 	// cleanup
@@ -348,189 +453,119 @@ func Resize(buf []byte, o Options) ([]byte, error) {
 		C.vips_error_clear()
 	}()
 
-	// defaults
-	if o.Quality == 0 {
-		o.Quality = 100
+	// Do shrink on load by default, however don't do it once any transform
+	// has made the in-memory image diverge from what a fresh decode of buf
+	// would produce.
+	useShrinkOnLoad := typ == JPEG || typ == WEBP
+
+	if typ == JPEG && o.wantAutoRotate() {
+		if orientation := readOrientation(image); orientation > 1 {
+			angle, flip, flop := orientationToTransform(orientation)
+			tmpImage, err := rotateAndFlipImage(image, Options{Rotate: angle, Flip: flip, Flop: flop})
+			if err != nil {
+				return nil, err
+			}
+
+			useShrinkOnLoad = false
+			image = tmpImage
+
+			stripOrientationTag(image)
+		}
 	}
 
-	if image == nil {
-		return nil, errors.New("unknown image format")
+	if o.AreaWidth > 0 && o.AreaHeight > 0 {
+		tmpImage, err := extractOrEmbedImage(image, o)
+		if err != nil {
+			return nil, err
+		}
+
+		useShrinkOnLoad = false
+		image = tmpImage
 	}
 
 	cropRect := validateCrop(image, o.CropRect)
 
 	if cropRect != nil {
 		tmpImage, err := Crop(image, cropRect.Left, cropRect.Top, cropRect.Width, cropRect.Height)
-
 		if err != nil {
 			C.g_object_unref(C.gpointer(image))
 			return nil, err
 		}
 
 		C.g_object_unref(C.gpointer(image))
-
-		// We've cropped the image, no longer safe to do shrinkOnLoad
 		useShrinkOnLoad = false
 		image = tmpImage
 	}
 
-	// get WxH
-	inWidth := int(image.Xsize)
-	inHeight := int(image.Ysize)
-
-	// prepare for factor
-	factor := 0.0
-
-	// image calculations
-	switch {
-	// Fixed width and height
-	case o.Width > 0 && o.Height > 0:
-		xf := float64(inWidth) / float64(o.Width)
-		yf := float64(inHeight) / float64(o.Height)
-		if o.Crop {
-			factor = math.Min(xf, yf)
-		} else {
-			factor = math.Max(xf, yf)
+	if o.Rotate != D0 || o.Flip || o.Flop {
+		tmpImage, err := rotateAndFlipImage(image, o)
+		if err != nil {
+			return nil, err
 		}
-	// Fixed width, auto height
-	case o.Width > 0:
-		factor = float64(inWidth) / float64(o.Width)
-		o.Height = int(math.Floor(float64(inHeight) / factor))
-	// Fixed height, auto width
-	case o.Height > 0:
-		factor = float64(inHeight) / float64(o.Height)
-		o.Width = int(math.Floor(float64(inWidth) / factor))
-	// Identity transform
-	default:
-		factor = 1
-		o.Width = inWidth
-		o.Height = inHeight
-	}
 
-	// shrink
-	shrink := int(math.Floor(factor))
-	if shrink < 1 {
-		shrink = 1
+		useShrinkOnLoad = false
+		image = tmpImage
 	}
 
-	// residual
-	residual := float64(shrink) / factor
-
-	// Do not enlarge the output if the input width *or* height are already less than the required dimensions
-	if !o.Enlarge {
-		if inWidth < o.Width && inHeight < o.Height {
-			factor = 1
-			shrink = 1
-			residual = 0
-			o.Width = inWidth
-			o.Height = inHeight
-		}
-	}
+	factor, shrink, residual := computeShrinkFactor(int(image.Xsize), int(image.Ysize), &o)
 
-	// Try to use libjpeg shrink-on-load
-	shrinkOnLoad := 1
-	if typ == JPEG && shrink >= 2 {
+	// Try to use the format's native shrink-on-load: libjpeg for JPEG, and
+	// (from libvips 8.3 onwards, where vips_webpload wired up the option)
+	// libwebp for WebP. Older libvips falls through to the plain vips_shrink
+	// path in transformImage.
+	webpShrinkOnLoad := typ == WEBP && vipsVersionAtLeast(8, 3)
+	shrinkOnLoadFactor := 1
+	if (typ == JPEG || webpShrinkOnLoad) && shrink >= 2 {
 		switch {
 		case shrink >= 8:
 			factor = factor / 8
-			shrinkOnLoad = 8
+			shrinkOnLoadFactor = 8
 		case shrink >= 4:
 			factor = factor / 4
-			shrinkOnLoad = 4
+			shrinkOnLoadFactor = 4
 		case shrink >= 2:
 			factor = factor / 2
-			shrinkOnLoad = 2
+			shrinkOnLoadFactor = 2
 		}
 	}
 
-	if useShrinkOnLoad && shrinkOnLoad > 1 {
-		// Recalculate integral shrink and double residual
+	if useShrinkOnLoad && shrinkOnLoadFactor > 1 {
 		factor = math.Max(factor, 1.0)
 		shrink = int(math.Floor(factor))
 		residual = float64(shrink) / factor
-		// Reload input using shrink-on-load
-		err := C.vips_jpegload_buffer_shrink(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &tmpImage, C.int(shrinkOnLoad))
-		C.g_object_unref(C.gpointer(image))
-		image = tmpImage
-		if err != 0 {
-			return nil, resizeError()
-		}
-	}
 
-	if shrink > 1 {
-		// Use vips_shrink with the integral reduction
-		err := C.vips_shrink_0(image, &tmpImage, C.double(float64(shrink)), C.double(float64(shrink)))
+		tmpImage, err := shrinkOnLoad(buf, typ, shrinkOnLoadFactor)
 		C.g_object_unref(C.gpointer(image))
-		image = tmpImage
-		if err != 0 {
-			return nil, resizeError()
+		if err != nil {
+			return nil, err
 		}
-
-		// Recalculate residual float based on dimensions of required vs shrunk images
-		shrunkWidth := int(image.Xsize)
-		shrunkHeight := int(image.Ysize)
-
-		residualx := float64(o.Width) / float64(shrunkWidth)
-		residualy := float64(o.Height) / float64(shrunkHeight)
-		residual = math.Min(residualx, residualy)
-	}
-
-	// Use vips_affine with the remaining float part
-	if residual != 0 {
-		// Create interpolator - "bilinear" (default), "bicubic" or "nohalo"
-		is := C.CString(o.Interpolator.String())
-		interpolator := C.vips_interpolate_new(is)
-
-		// Perform affine transformation
-		err := C.vips_affine_interpolator(image, &tmpImage, C.double(residual), 0, 0, C.double(residual), interpolator)
-		C.g_object_unref(C.gpointer(image))
-
 		image = tmpImage
-
-		C.free(unsafe.Pointer(is))
-		C.g_object_unref(C.gpointer(interpolator))
-
-		if err != 0 {
-			return nil, resizeError()
-		}
 	}
 
-	// Switch to sRGB before we do anything else because flattening
-	// with some other colorspece will not bode well in most other cases
-	// Always convert to sRGB colour space
-	if -1 != C.vips_colourspace_0(image, &tmpImage, C.VIPS_INTERPRETATION_sRGB) {
-		C.g_object_unref(C.gpointer(image))
-		image = tmpImage
+	image, err = transformImage(image, o, shrink, residual)
+	if err != nil {
+		return nil, err
 	}
 
-	// Only flatten if we're not running CMYK and we're rocking
-	// more than the standard 3 bands
-	if image.Type != C.VIPS_INTERPRETATION_CMYK && image.Bands > 3 {
-		if -1 != C.vips_flatten_0(image, &tmpImage) {
-			C.g_object_unref(C.gpointer(image))
-			image = tmpImage
-		}
+	image, err = zoomImage(image, o.Zoom)
+	if err != nil {
+		return nil, err
 	}
 
-	// Apply blur if needed
-	if o.BlurAmount > 0 {
-		if -1 != C.vips_gaussian_blur(image, &tmpImage, C.double(o.BlurAmount)) {
+	// If requested, crop down to the exact target dimensions once resized
+	if o.Crop && (int(image.Xsize) > o.Width || int(image.Ysize) > o.Height) {
+		left, top := sharpCalcCrop(int(image.Xsize), int(image.Ysize), o.Width, o.Height, o.Gravity)
+		croppedImage, err := Crop(image, uint(top), uint(left), uint(o.Width), uint(o.Height))
+		if err != nil {
 			C.g_object_unref(C.gpointer(image))
-			image = tmpImage
+			return nil, err
 		}
-	}
-
-	// Finally save
-	length := C.size_t(0)
-	var ptr unsafe.Pointer
-	C.vips_jpegsave_custom(image, &ptr, &length, 1, C.int(o.Quality), 0)
-	C.g_object_unref(C.gpointer(image))
 
-	// get back the buffer
-	buf = C.GoBytes(ptr, C.int(length))
-	C.g_free(C.gpointer(ptr))
+		C.g_object_unref(C.gpointer(image))
+		image = croppedImage
+	}
 
-	return buf, nil
+	return saveImage(image, o)
 }
 
 func resizeError() error {
@@ -538,36 +573,3 @@ func resizeError() error {
 	C.vips_error_clear()
 	return errors.New(s)
 }
-
-type Gravity int
-
-const (
-	CENTRE Gravity = 1 << iota
-	NORTH
-	EAST
-	SOUTH
-	WEST
-)
-
-func sharpCalcCrop(inWidth, inHeight, outWidth, outHeight int, gravity Gravity) (int, int) {
-	left := (inWidth - outWidth + 1) / 2
-	top := (inHeight - outHeight + 1) / 2
-
-	if (gravity & NORTH) != 0 {
-		top = 0
-	}
-
-	if (gravity & EAST) != 0 {
-		left = inWidth - outWidth
-	}
-
-	if (gravity & SOUTH) != 0 {
-		top = inHeight - outHeight
-	}
-
-	if (gravity & WEST) != 0 {
-		left = 0
-	}
-
-	return left, top
-}